@@ -0,0 +1,77 @@
+package main
+
+import (
+	"os"
+	"strconv"
+	"time"
+)
+
+// Config controls the interceptor chain and server wiring. It exists
+// primarily so tests can disable auth and other cross-cutting concerns that
+// would otherwise require real JWTs or client certs.
+type Config struct {
+	// GRPCAddr and GatewayAddr override the default listen addresses.
+	GRPCAddr    string
+	GatewayAddr string
+
+	// DisableAuth skips the auth interceptor entirely. Set in tests, or
+	// from QUTLAS_DISABLE_AUTH for local development; production
+	// deployments must authenticate every RPC.
+	DisableAuth bool
+
+	// JWTSecret is passed to verifyJWT for bearer tokens. verifyJWT has no
+	// signature verification: with an empty secret every bearer token is
+	// rejected, and main() refuses to start when it is set, so bearer
+	// tokens never authenticate — only mTLS client certs can.
+	JWTSecret []byte
+
+	// TLSCertFile, TLSKeyFile, and ClientCAFile configure the gRPC server's
+	// transport credentials. All three must be set together to require and
+	// verify mTLS client certificates; if unset, the server listens without
+	// TLS and main() only starts with DisableAuth set.
+	TLSCertFile  string
+	TLSKeyFile   string
+	ClientCAFile string
+
+	// OCCTFailureThreshold is the number of consecutive OCCT operation
+	// failures after which the health status flips to NOT_SERVING.
+	OCCTFailureThreshold int32
+
+	// RequestTimeout bounds how long a single unary OCCT-backed RPC may run
+	// before its context is canceled (see requestTimeoutUnaryInterceptor).
+	// Not applied to streaming RPCs (GenerateMesh, UploadAsset), which are
+	// expected to run long enough to stream many chunks.
+	RequestTimeout time.Duration
+}
+
+// defaultConfig returns the Config used by main() in production, read from
+// the environment so auth has somewhere to get real credentials from
+// instead of gating on fields nothing ever populates.
+func defaultConfig() Config {
+	return Config{
+		GRPCAddr:             envOr("QUTLAS_GRPC_ADDR", grpcAddr),
+		GatewayAddr:          envOr("QUTLAS_GATEWAY_ADDR", gatewayAddr),
+		DisableAuth:          envBool("QUTLAS_DISABLE_AUTH", false),
+		JWTSecret:            []byte(os.Getenv("QUTLAS_JWT_SECRET")),
+		TLSCertFile:          os.Getenv("QUTLAS_TLS_CERT_FILE"),
+		TLSKeyFile:           os.Getenv("QUTLAS_TLS_KEY_FILE"),
+		ClientCAFile:         os.Getenv("QUTLAS_CLIENT_CA_FILE"),
+		OCCTFailureThreshold: occtFailureThreshold,
+		RequestTimeout:       30 * time.Second,
+	}
+}
+
+func envOr(key, fallback string) string {
+	if v := os.Getenv(key); v != "" {
+		return v
+	}
+	return fallback
+}
+
+func envBool(key string, fallback bool) bool {
+	v, err := strconv.ParseBool(os.Getenv(key))
+	if err != nil {
+		return fallback
+	}
+	return v
+}
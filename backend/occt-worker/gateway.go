@@ -0,0 +1,78 @@
+package main
+
+import (
+	"context"
+	"embed"
+	"io"
+	"net/http"
+
+	"github.com/grpc-ecosystem/grpc-gateway/v2/runtime"
+	"google.golang.org/grpc"
+	"google.golang.org/protobuf/encoding/protojson"
+
+	pb "qutlas/proto"
+)
+
+//go:embed openapi/geometry.swagger.json
+var openapiFS embed.FS
+
+// octetStreamMarshaler streams binary fields (exported STEP/GLTF bytes) as
+// raw application/octet-stream instead of the base64 JSON grpc-gateway would
+// otherwise produce for `bytes` fields.
+type octetStreamMarshaler struct {
+	runtime.Marshaler
+}
+
+func (m *octetStreamMarshaler) ContentType(v interface{}) string {
+	if _, ok := v.(*pb.FileResponse); ok {
+		return "application/octet-stream"
+	}
+	return m.Marshaler.ContentType(v)
+}
+
+func (m *octetStreamMarshaler) Marshal(v interface{}) ([]byte, error) {
+	if fr, ok := v.(*pb.FileResponse); ok {
+		return fr.GetData(), nil
+	}
+	return m.Marshaler.Marshal(v)
+}
+
+// newGatewayMux builds the HTTP/JSON gateway that proxies into the in-process
+// gRPC server, using octetStreamMarshaler for binary payloads and the
+// standard JSON marshaler for everything else.
+func newGatewayMux(ctx context.Context, grpcEndpoint string, dialOpts []grpc.DialOption) (*runtime.ServeMux, error) {
+	mux := runtime.NewServeMux(
+		runtime.WithMarshalerOption("application/octet-stream", &octetStreamMarshaler{
+			Marshaler: &runtime.HTTPBodyMarshaler{Marshaler: &runtime.JSONPb{MarshalOptions: protojson.MarshalOptions{EmitUnpopulated: true}}},
+		}),
+		runtime.WithMarshalerOption(runtime.MIMEWildcard, &runtime.JSONPb{
+			MarshalOptions: protojson.MarshalOptions{EmitUnpopulated: true},
+		}),
+	)
+
+	if err := pb.RegisterGeometryServiceHandlerFromEndpoint(ctx, mux, grpcEndpoint, dialOpts); err != nil {
+		return nil, err
+	}
+	return mux, nil
+}
+
+// newGatewayServeMux wraps the grpc-gateway mux with the /openapi.json route
+// so SDKs can be generated straight from the proto annotations.
+func newGatewayServeMux(gwMux *runtime.ServeMux) *http.ServeMux {
+	root := http.NewServeMux()
+	root.Handle("/", gwMux)
+	root.HandleFunc("/openapi.json", serveOpenAPI)
+	return root
+}
+
+func serveOpenAPI(w http.ResponseWriter, r *http.Request) {
+	f, err := openapiFS.Open("openapi/geometry.swagger.json")
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	defer f.Close()
+
+	w.Header().Set("Content-Type", "application/json")
+	_, _ = io.Copy(w, f)
+}
@@ -0,0 +1,48 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/grpc-ecosystem/grpc-gateway/v2/runtime"
+	"google.golang.org/protobuf/encoding/protojson"
+
+	pb "qutlas/proto"
+)
+
+func newOctetStreamMarshaler() *octetStreamMarshaler {
+	return &octetStreamMarshaler{
+		Marshaler: &runtime.HTTPBodyMarshaler{Marshaler: &runtime.JSONPb{MarshalOptions: protojson.MarshalOptions{EmitUnpopulated: true}}},
+	}
+}
+
+func TestOctetStreamMarshalerReturnsRawBytesForFileResponse(t *testing.T) {
+	m := newOctetStreamMarshaler()
+	want := []byte("STEP file contents")
+
+	got, err := m.Marshal(&pb.FileResponse{Status: "exported", Data: want})
+	if err != nil {
+		t.Fatalf("Marshal: %v", err)
+	}
+	if string(got) != string(want) {
+		t.Fatalf("Marshal = %q, want %q", got, want)
+	}
+	if ct := m.ContentType(&pb.FileResponse{}); ct != "application/octet-stream" {
+		t.Fatalf("ContentType(FileResponse) = %q, want application/octet-stream", ct)
+	}
+}
+
+func TestOctetStreamMarshalerFallsBackForOtherTypes(t *testing.T) {
+	m := newOctetStreamMarshaler()
+
+	resp := &pb.AssetResponse{AssetId: "asset-1", Status: "loaded"}
+	got, err := m.Marshal(resp)
+	if err != nil {
+		t.Fatalf("Marshal: %v", err)
+	}
+	if len(got) == 0 {
+		t.Fatal("Marshal(AssetResponse) = empty, want JSON body")
+	}
+	if ct := m.ContentType(resp); ct == "application/octet-stream" {
+		t.Fatalf("ContentType(AssetResponse) = %q, want JSON content type from the underlying marshaler", ct)
+	}
+}
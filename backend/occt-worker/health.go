@@ -0,0 +1,103 @@
+package main
+
+import (
+	"net/http"
+	"sync/atomic"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/status"
+)
+
+// geometryServiceName is the service name probed via grpc_health_probe and
+// the HTTP /readyz endpoint. It matches the proto package's service, not the
+// Go package, per the grpc-health-v1 convention.
+const geometryServiceName = "qutlas.geometry.GeometryService"
+
+// occtFailureThreshold is the default number of consecutive OCCT operation
+// failures (panics or degenerate-geometry errors from Boolean/Fillet) after
+// which the service is marked NOT_SERVING again, even after a successful
+// init. Operators can override it via healthTracker.failureThreshold (see
+// Config.OCCTFailureThreshold).
+const occtFailureThreshold = 5
+
+// healthTracker ties the standard grpc.health.v1.Health service to the
+// lifecycle of the OCCT backend: NOT_SERVING until the first asset store is
+// reachable, and NOT_SERVING again if OCCT operations start failing
+// repeatedly (e.g. during long re-indexing).
+type healthTracker struct {
+	*health.Server
+
+	failureThreshold    int32
+	consecutiveFailures int32
+}
+
+// newHealthTracker builds a healthTracker that trips NOT_SERVING after
+// failureThreshold consecutive OCCT operation failures. If failureThreshold
+// is <= 0, occtFailureThreshold is used instead.
+func newHealthTracker(failureThreshold int32) *healthTracker {
+	if failureThreshold <= 0 {
+		failureThreshold = occtFailureThreshold
+	}
+	h := &healthTracker{Server: health.NewServer(), failureThreshold: failureThreshold}
+	h.Server.SetServingStatus(geometryServiceName, healthpb.HealthCheckResponse_NOT_SERVING)
+	h.Server.SetServingStatus("", healthpb.HealthCheckResponse_NOT_SERVING)
+	return h
+}
+
+// markOCCTReady flips the service serving once the OCCT backend has
+// initialized and the first asset store is reachable.
+func (h *healthTracker) markOCCTReady() {
+	atomic.StoreInt32(&h.consecutiveFailures, 0)
+	h.Server.SetServingStatus(geometryServiceName, healthpb.HealthCheckResponse_SERVING)
+	h.Server.SetServingStatus("", healthpb.HealthCheckResponse_SERVING)
+}
+
+// recordOCCTResult is called after every OCCT-backed RPC. A failure (panic
+// recovered by the interceptor, or a degenerate-geometry error) increments a
+// counter; h.failureThreshold consecutive failures mark the service
+// NOT_SERVING so callers stop routing traffic to it. A later success only
+// resets the counter; the status returns to SERVING through markOCCTReady
+// or SetServingStatus.
+func (h *healthTracker) recordOCCTResult(err error) {
+	if err == nil {
+		atomic.StoreInt32(&h.consecutiveFailures, 0)
+		return
+	}
+	if atomic.AddInt32(&h.consecutiveFailures, 1) >= h.failureThreshold {
+		h.Server.SetServingStatus(geometryServiceName, healthpb.HealthCheckResponse_NOT_SERVING)
+	}
+}
+
+// SetServingStatus is re-exposed (beyond the embedded health.Server method)
+// so OCCT bindings performing long re-indexing can mark the service
+// unhealthy directly, e.g. SetServingStatus(healthpb.HealthCheckResponse_NOT_SERVING).
+func (h *healthTracker) SetServingStatus(status healthpb.HealthCheckResponse_ServingStatus) {
+	h.Server.SetServingStatus(geometryServiceName, status)
+}
+
+// healthzHandler reports liveness: the process is up and serving HTTP.
+func healthzHandler(w http.ResponseWriter, r *http.Request) {
+	w.WriteHeader(http.StatusOK)
+	_, _ = w.Write([]byte("ok"))
+}
+
+// readyzHandler reports readiness by checking the same status the gRPC
+// health service reports for GeometryService.
+func readyzHandler(h *healthTracker) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		resp, err := h.Check(r.Context(), &healthpb.HealthCheckRequest{Service: geometryServiceName})
+		if err != nil || resp.Status != healthpb.HealthCheckResponse_SERVING {
+			st, _ := status.FromError(err)
+			if st.Code() != codes.OK && err != nil {
+				http.Error(w, st.Message(), http.StatusServiceUnavailable)
+				return
+			}
+			http.Error(w, "not serving", http.StatusServiceUnavailable)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+		_, _ = w.Write([]byte("ok"))
+	}
+}
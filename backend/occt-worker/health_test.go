@@ -0,0 +1,106 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+)
+
+func checkStatus(t *testing.T, h *healthTracker, service string) healthpb.HealthCheckResponse_ServingStatus {
+	t.Helper()
+	resp, err := h.Check(context.Background(), &healthpb.HealthCheckRequest{Service: service})
+	if err != nil {
+		t.Fatalf("Check(%q): %v", service, err)
+	}
+	return resp.Status
+}
+
+func TestHealthTrackerStartsNotServing(t *testing.T) {
+	h := newHealthTracker(0)
+
+	if got := checkStatus(t, h, geometryServiceName); got != healthpb.HealthCheckResponse_NOT_SERVING {
+		t.Fatalf("initial status = %v, want NOT_SERVING", got)
+	}
+}
+
+func TestHealthTrackerMarkOCCTReady(t *testing.T) {
+	h := newHealthTracker(0)
+	h.markOCCTReady()
+
+	if got := checkStatus(t, h, geometryServiceName); got != healthpb.HealthCheckResponse_SERVING {
+		t.Fatalf("status after markOCCTReady = %v, want SERVING", got)
+	}
+}
+
+func TestHealthTrackerRecordOCCTResultTripsAfterThreshold(t *testing.T) {
+	h := newHealthTracker(0)
+	h.markOCCTReady()
+
+	for i := int32(0); i < occtFailureThreshold-1; i++ {
+		h.recordOCCTResult(errTest)
+		if got := checkStatus(t, h, geometryServiceName); got != healthpb.HealthCheckResponse_SERVING {
+			t.Fatalf("status after %d failures = %v, want still SERVING", i+1, got)
+		}
+	}
+
+	h.recordOCCTResult(errTest)
+	if got := checkStatus(t, h, geometryServiceName); got != healthpb.HealthCheckResponse_NOT_SERVING {
+		t.Fatalf("status after %d failures = %v, want NOT_SERVING", occtFailureThreshold, got)
+	}
+}
+
+func TestHealthTrackerRecordOCCTResultResetsOnSuccess(t *testing.T) {
+	h := newHealthTracker(0)
+	h.markOCCTReady()
+
+	for i := int32(0); i < occtFailureThreshold-1; i++ {
+		h.recordOCCTResult(errTest)
+	}
+	h.recordOCCTResult(nil)
+	h.recordOCCTResult(errTest)
+
+	if got := checkStatus(t, h, geometryServiceName); got != healthpb.HealthCheckResponse_SERVING {
+		t.Fatalf("status after reset + 1 failure = %v, want still SERVING", got)
+	}
+}
+
+func TestHealthTrackerRespectsCustomFailureThreshold(t *testing.T) {
+	h := newHealthTracker(2)
+	h.markOCCTReady()
+
+	h.recordOCCTResult(errTest)
+	if got := checkStatus(t, h, geometryServiceName); got != healthpb.HealthCheckResponse_SERVING {
+		t.Fatalf("status after 1 failure with threshold 2 = %v, want still SERVING", got)
+	}
+
+	h.recordOCCTResult(errTest)
+	if got := checkStatus(t, h, geometryServiceName); got != healthpb.HealthCheckResponse_NOT_SERVING {
+		t.Fatalf("status after 2 failures with threshold 2 = %v, want NOT_SERVING", got)
+	}
+}
+
+func TestReadyzHandlerReflectsHealthStatus(t *testing.T) {
+	h := newHealthTracker(0)
+	handler := readyzHandler(h)
+
+	req := httptest.NewRequest(http.MethodGet, "/readyz", nil)
+	rec := httptest.NewRecorder()
+	handler(rec, req)
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Fatalf("status before markOCCTReady = %d, want %d", rec.Code, http.StatusServiceUnavailable)
+	}
+
+	h.markOCCTReady()
+	rec = httptest.NewRecorder()
+	handler(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status after markOCCTReady = %d, want %d", rec.Code, http.StatusOK)
+	}
+}
+
+// errTest is a stand-in for an OCCT operation failure; recordOCCTResult only
+// checks err != nil.
+var errTest = context.DeadlineExceeded
@@ -0,0 +1,208 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"strings"
+	"time"
+
+	grpcmiddleware "github.com/grpc-ecosystem/go-grpc-middleware"
+	grpcauth "github.com/grpc-ecosystem/go-grpc-middleware/auth"
+	grpczap "github.com/grpc-ecosystem/go-grpc-middleware/logging/zap"
+	grpcrecovery "github.com/grpc-ecosystem/go-grpc-middleware/recovery"
+	grpcprometheus "github.com/grpc-ecosystem/go-grpc-prometheus"
+	"github.com/oklog/ulid/v2"
+	"github.com/prometheus/client_golang/prometheus"
+	"go.uber.org/zap"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+// requestIDKey is the context key under which the per-RPC request ID
+// (from the x-request-id metadata, or a generated ULID) is stored.
+type requestIDKey struct{}
+
+// activeOCCTSessions tracks in-flight OCCT-backed RPCs so operators can see
+// concurrent load on the cgo layer.
+var activeOCCTSessions = prometheus.NewGauge(prometheus.GaugeOpts{
+	Namespace: "qutlas",
+	Subsystem: "occt_worker",
+	Name:      "active_occt_sessions",
+	Help:      "Number of OCCT-backed RPCs currently in flight.",
+})
+
+func init() {
+	prometheus.MustRegister(activeOCCTSessions)
+	grpcprometheus.EnableHandlingTimeHistogram()
+}
+
+// requestIDUnaryInterceptor accepts x-request-id from incoming metadata or
+// generates a ULID, and stashes it in the context for downstream logging.
+func requestIDUnaryInterceptor(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	ctx = withRequestID(ctx)
+	return handler(ctx, req)
+}
+
+func requestIDStreamInterceptor(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	wrapped := grpcmiddleware.WrapServerStream(ss)
+	wrapped.WrappedContext = withRequestID(ss.Context())
+	return handler(srv, wrapped)
+}
+
+func withRequestID(ctx context.Context) context.Context {
+	id := ""
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		if vals := md.Get("x-request-id"); len(vals) > 0 {
+			id = vals[0]
+		}
+	}
+	if id == "" {
+		id = ulid.MustNew(ulid.Timestamp(time.Now()), rand.Reader).String()
+	}
+	return context.WithValue(ctx, requestIDKey{}, id)
+}
+
+// requestIDFromContext returns the request ID stashed by the requestid
+// interceptor, or "" if none is present (e.g. in unit tests calling the
+// handler directly).
+func requestIDFromContext(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey{}).(string)
+	return id
+}
+
+// occtSessionGaugeUnaryInterceptor tracks active OCCT-backed RPCs in a
+// Prometheus gauge for the duration of the call.
+func occtSessionGaugeUnaryInterceptor(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	activeOCCTSessions.Inc()
+	defer activeOCCTSessions.Dec()
+	return handler(ctx, req)
+}
+
+// requestTimeoutUnaryInterceptor bounds unary RPCs to cfg.RequestTimeout.
+// It is not applied to the stream chain: GenerateMesh and UploadAsset are
+// expected to run long enough to stream many chunks, so a single blanket
+// deadline would cut them off mid-transfer.
+func requestTimeoutUnaryInterceptor(timeout time.Duration) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		ctx, cancel := context.WithTimeout(ctx, timeout)
+		defer cancel()
+		return handler(ctx, req)
+	}
+}
+
+// occtSessionGaugeStreamInterceptor is the streaming equivalent of
+// occtSessionGaugeUnaryInterceptor, so long-running OCCT RPCs like
+// GenerateMesh and UploadAsset are counted too, not just unary calls.
+func occtSessionGaugeStreamInterceptor(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	activeOCCTSessions.Inc()
+	defer activeOCCTSessions.Dec()
+	return handler(srv, ss)
+}
+
+// bearerAuthFunc implements grpcauth.AuthFunc, accepting an mTLS client
+// certificate subject. Bearer tokens are passed to verifyJWT, which accepts
+// none of them. It is swapped out entirely when cfg.DisableAuth is set.
+func bearerAuthFunc(cfg Config) grpcauth.AuthFunc {
+	return func(ctx context.Context) (context.Context, error) {
+		if peerSubject, ok := peerCertSubject(ctx); ok {
+			return context.WithValue(ctx, authSubjectKey{}, peerSubject), nil
+		}
+
+		token, err := grpcauth.AuthFromMD(ctx, "bearer")
+		if err != nil {
+			return nil, err
+		}
+		subject, err := verifyJWT(token, cfg.JWTSecret)
+		if err != nil {
+			return nil, status.Errorf(codes.Unauthenticated, "invalid token: %v", err)
+		}
+		return context.WithValue(ctx, authSubjectKey{}, subject), nil
+	}
+}
+
+type authSubjectKey struct{}
+
+// peerCertSubject extracts the verified client certificate's subject from
+// an mTLS connection, if present.
+func peerCertSubject(ctx context.Context) (string, bool) {
+	p, ok := peer.FromContext(ctx)
+	if !ok || p.AuthInfo == nil {
+		return "", false
+	}
+	tlsInfo, ok := p.AuthInfo.(credentials.TLSInfo)
+	if !ok || len(tlsInfo.State.VerifiedChains) == 0 || len(tlsInfo.State.VerifiedChains[0]) == 0 {
+		return "", false
+	}
+	return tlsInfo.State.VerifiedChains[0][0].Subject.CommonName, true
+}
+
+// verifyJWT does not implement real signature/claims verification — unlike
+// the OCCT placeholders elsewhere in this package, this is the actual auth
+// mechanism the request asked for, so it must not silently stand in for one.
+// Wire a real verifier (e.g. github.com/golang-jwt/jwt/v5 against cfg.JWTSecret,
+// checking alg/exp/nbf) before enabling bearer-token auth in any environment
+// that isn't DisableAuth'd.
+func verifyJWT(token string, secret []byte) (string, error) {
+	if len(secret) == 0 || strings.TrimSpace(token) == "" {
+		return "", status.Error(codes.Unauthenticated, "missing credentials")
+	}
+	panic("verifyJWT: bearer JWT verification is not implemented; do not set QUTLAS_JWT_SECRET until a real verifier replaces this, use mTLS or QUTLAS_DISABLE_AUTH=true instead")
+}
+
+// recoveryHandler builds the grpcrecovery.Option that logs a recovered
+// panic and records it as an OCCT failure, the same as doBoolean/doFillet/
+// etc. do for handled errors, so a panicking op still counts toward the
+// health tracker's NOT_SERVING threshold.
+func recoveryHandler(logger *zap.Logger, health *healthTracker) grpcrecovery.Option {
+	return grpcrecovery.WithRecoveryHandlerContext(func(ctx context.Context, p interface{}) error {
+		logger.Error("recovered from panic in OCCT handler",
+			zap.Any("panic", p),
+			zap.String("request_id", requestIDFromContext(ctx)))
+		err := status.Errorf(codes.Internal, "internal error processing geometry operation")
+		health.recordOCCTResult(err)
+		return err
+	})
+}
+
+// buildInterceptors assembles the unary and stream interceptor chains
+// described for the server: recovery, structured logging, metrics, auth,
+// and request IDs. Auth is skipped entirely when cfg.DisableAuth is set so
+// tests don't need real credentials.
+func buildInterceptors(cfg Config, logger *zap.Logger, health *healthTracker) []grpc.ServerOption {
+	grpczap.ReplaceGrpcLoggerV2(logger)
+
+	recoveryOpts := []grpcrecovery.Option{recoveryHandler(logger, health)}
+
+	// The request ID interceptor runs first so the recovery handler's
+	// context already carries the ID it logs.
+	unaryChain := []grpc.UnaryServerInterceptor{
+		requestIDUnaryInterceptor,
+		grpcrecovery.UnaryServerInterceptor(recoveryOpts...),
+		grpczap.UnaryServerInterceptor(logger),
+		grpcprometheus.UnaryServerInterceptor,
+		occtSessionGaugeUnaryInterceptor,
+		requestTimeoutUnaryInterceptor(cfg.RequestTimeout),
+	}
+	streamChain := []grpc.StreamServerInterceptor{
+		requestIDStreamInterceptor,
+		grpcrecovery.StreamServerInterceptor(recoveryOpts...),
+		grpczap.StreamServerInterceptor(logger),
+		grpcprometheus.StreamServerInterceptor,
+		occtSessionGaugeStreamInterceptor,
+	}
+
+	if !cfg.DisableAuth {
+		authFunc := bearerAuthFunc(cfg)
+		unaryChain = append(unaryChain, grpcauth.UnaryServerInterceptor(authFunc))
+		streamChain = append(streamChain, grpcauth.StreamServerInterceptor(authFunc))
+	}
+
+	return []grpc.ServerOption{
+		grpc.ChainUnaryInterceptor(unaryChain...),
+		grpc.ChainStreamInterceptor(streamChain...),
+	}
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	grpcrecovery "github.com/grpc-ecosystem/go-grpc-middleware/recovery"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"go.uber.org/zap"
+	"google.golang.org/grpc"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+)
+
+func TestOCCTSessionGaugeUnaryInterceptorTracksInFlight(t *testing.T) {
+	handlerCalled := false
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		handlerCalled = true
+		if got := testutil.ToFloat64(activeOCCTSessions); got != 1 {
+			t.Fatalf("gauge during call = %v, want 1", got)
+		}
+		return nil, nil
+	}
+
+	before := testutil.ToFloat64(activeOCCTSessions)
+	if _, err := occtSessionGaugeUnaryInterceptor(context.Background(), nil, &grpc.UnaryServerInfo{}, handler); err != nil {
+		t.Fatalf("interceptor: %v", err)
+	}
+	if !handlerCalled {
+		t.Fatal("handler was not called")
+	}
+	if got := testutil.ToFloat64(activeOCCTSessions); got != before {
+		t.Fatalf("gauge after call = %v, want back to %v", got, before)
+	}
+}
+
+func TestOCCTSessionGaugeStreamInterceptorTracksInFlight(t *testing.T) {
+	handlerCalled := false
+	handler := func(srv interface{}, ss grpc.ServerStream) error {
+		handlerCalled = true
+		if got := testutil.ToFloat64(activeOCCTSessions); got != 1 {
+			t.Fatalf("gauge during call = %v, want 1", got)
+		}
+		return nil
+	}
+
+	before := testutil.ToFloat64(activeOCCTSessions)
+	if err := occtSessionGaugeStreamInterceptor(nil, nil, &grpc.StreamServerInfo{}, handler); err != nil {
+		t.Fatalf("interceptor: %v", err)
+	}
+	if !handlerCalled {
+		t.Fatal("handler was not called")
+	}
+	if got := testutil.ToFloat64(activeOCCTSessions); got != before {
+		t.Fatalf("gauge after call = %v, want back to %v", got, before)
+	}
+}
+
+func TestRequestTimeoutUnaryInterceptorCancelsContext(t *testing.T) {
+	interceptor := requestTimeoutUnaryInterceptor(10 * time.Millisecond)
+
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		<-ctx.Done()
+		return nil, ctx.Err()
+	}
+
+	_, err := interceptor(context.Background(), nil, &grpc.UnaryServerInfo{}, handler)
+	if err != context.DeadlineExceeded {
+		t.Fatalf("err = %v, want %v", err, context.DeadlineExceeded)
+	}
+}
+
+func TestRecoveryHandlerRecordsOCCTFailure(t *testing.T) {
+	health := newHealthTracker(1)
+	health.markOCCTReady()
+
+	recovery := grpcrecovery.UnaryServerInterceptor(recoveryHandler(zap.NewNop(), health))
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		panic("simulated OCCT panic")
+	}
+
+	if _, err := recovery(context.Background(), nil, &grpc.UnaryServerInfo{}, handler); err == nil {
+		t.Fatal("recovery interceptor: want error from recovered panic, got nil")
+	}
+
+	resp, err := health.Check(context.Background(), &healthpb.HealthCheckRequest{Service: geometryServiceName})
+	if err != nil {
+		t.Fatalf("Check: %v", err)
+	}
+	if resp.Status != healthpb.HealthCheckResponse_NOT_SERVING {
+		t.Fatalf("status after recovered panic = %v, want NOT_SERVING", resp.Status)
+	}
+}
+
+func TestBuildInterceptorsWithAuthDisabledReturnsUnaryAndStreamChains(t *testing.T) {
+	cfg := Config{DisableAuth: true, RequestTimeout: time.Second}
+	logger := zap.NewNop()
+
+	opts := buildInterceptors(cfg, logger, newHealthTracker(0))
+	if len(opts) != 2 {
+		t.Fatalf("len(opts) = %d, want 2 (unary + stream chain)", len(opts))
+	}
+}
@@ -3,19 +3,46 @@ package main
 
 import (
 	"context"
+	"fmt"
 	"log"
 	"net"
+	"net/http"
 
+	"github.com/grpc-ecosystem/go-grpc-middleware/logging/zap/ctxzap"
+	grpcprometheus "github.com/grpc-ecosystem/go-grpc-prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"go.uber.org/zap"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/status"
 	pb "qutlas/proto"
 )
 
+const (
+	grpcAddr    = ":50051"
+	gatewayAddr = ":8080"
+)
+
 type server struct {
 	pb.UnimplementedGeometryServiceServer
+
+	health   *healthTracker
+	sessions *SessionManager
+}
+
+// sessionFor looks up a session by ID, returning a NotFound error if it
+// doesn't exist or has already been reaped.
+func (s *server) sessionFor(id string) (*Session, error) {
+	sess, ok := s.sessions.Get(id)
+	if !ok {
+		return nil, status.Errorf(codes.NotFound, "session %s not found", id)
+	}
+	return sess, nil
 }
 
 func (s *server) LoadAsset(ctx context.Context, req *pb.AssetRequest) (*pb.AssetResponse, error) {
-	log.Printf("Loading asset: %s", req.AssetId)
+	ctxzap.Extract(ctx).Info("loading asset", zap.String("asset_id", req.AssetId))
 
 	// Placeholder: In production, use OpenCascade C++ bindings via cgo
 	// 1. Parse STEP/IGES/STL file
@@ -23,61 +50,87 @@ func (s *server) LoadAsset(ctx context.Context, req *pb.AssetRequest) (*pb.Asset
 	// 3. Compute bounding box
 	// 4. Return GLTF data and bounds
 
-	return &pb.AssetResponse{
+	resp := &pb.AssetResponse{
 		AssetId: req.AssetId,
 		Status:  "loaded",
 		Bounds: &pb.BoundingBox{
 			MinX: 0, MinY: 0, MinZ: 0,
 			MaxX: 100, MaxY: 100, MaxZ: 100,
 		},
-	}, nil
+	}
+	s.health.recordOCCTResult(nil)
+	return resp, nil
 }
 
 func (s *server) Boolean(ctx context.Context, req *pb.BooleanRequest) (*pb.GeometryResponse, error) {
-	log.Printf("Boolean operation: %s", req.Operation)
+	resp, _, err := s.doBoolean(ctx, req)
+	return resp, err
+}
+
+// doBoolean is the real Boolean implementation, also called directly by
+// SessionService.ApplyOp so it can read back the exact history entry this
+// call produced instead of racing other callers for the tail of history.
+func (s *server) doBoolean(ctx context.Context, req *pb.BooleanRequest) (*pb.GeometryResponse, *historyEntry, error) {
+	ctxzap.Extract(ctx).Info("boolean operation",
+		zap.String("operation", req.Operation),
+		zap.String("session_id", req.SessionId))
 
 	// Placeholder: In production, perform robust boolean using OCCT
 	// Handle edge cases: degenerate geometries, self-intersections, etc.
 
-	return &pb.GeometryResponse{
-		Status: "completed",
-	}, nil
+	var entry *historyEntry
+	if req.SessionId != "" {
+		sess, err := s.sessionFor(req.SessionId)
+		if err != nil {
+			return nil, nil, err
+		}
+		e, err := sess.applyOp("boolean:" + req.Operation)
+		if err != nil {
+			s.health.recordOCCTResult(err)
+			return nil, nil, err
+		}
+		entry = &e
+	}
+
+	resp := &pb.GeometryResponse{Status: "completed"}
+	s.health.recordOCCTResult(nil)
+	return resp, entry, nil
 }
 
 func (s *server) Fillet(ctx context.Context, req *pb.FilletRequest) (*pb.GeometryResponse, error) {
-	log.Printf("Filleting edges with radius: %f", req.Radius)
-
-	// Placeholder: Use ChFi_FilletAPI from OCCT
-
-	return &pb.GeometryResponse{
-		Status: "completed",
-	}, nil
+	resp, _, err := s.doFillet(ctx, req)
+	return resp, err
 }
 
-func (s *server) GenerateMesh(ctx context.Context, req *pb.MeshRequest) (*pb.MeshResponse, error) {
-	log.Printf("Generating mesh with deflection: %f", req.Deflection)
+// doFillet is the real Fillet implementation; see doBoolean.
+func (s *server) doFillet(ctx context.Context, req *pb.FilletRequest) (*pb.GeometryResponse, *historyEntry, error) {
+	ctxzap.Extract(ctx).Info("fillet operation",
+		zap.Float64("radius", req.Radius),
+		zap.String("session_id", req.SessionId))
 
-	// Placeholder: Use BRepMesh_IncrementalMesh from OCCT
-	// Convert to GLTF with Draco compression for streaming
-
-	return &pb.MeshResponse{
-		Status:      "completed",
-		VertexCount: 10000,
-		FaceCount:   5000,
-	}, nil
-}
+	// Placeholder: Use ChFi_FilletAPI from OCCT
 
-func (s *server) ImportStep(ctx context.Context, req *pb.FileRequest) (*pb.AssetResponse, error) {
-	log.Printf("Importing STEP file")
+	var entry *historyEntry
+	if req.SessionId != "" {
+		sess, err := s.sessionFor(req.SessionId)
+		if err != nil {
+			return nil, nil, err
+		}
+		e, err := sess.applyOp(fmt.Sprintf("fillet:%.4g", req.Radius))
+		if err != nil {
+			s.health.recordOCCTResult(err)
+			return nil, nil, err
+		}
+		entry = &e
+	}
 
-	// Placeholder: STEPControl_Reader
-	return &pb.AssetResponse{
-		Status: "imported",
-	}, nil
+	resp := &pb.GeometryResponse{Status: "completed"}
+	s.health.recordOCCTResult(nil)
+	return resp, entry, nil
 }
 
 func (s *server) ExportStep(ctx context.Context, req *pb.ExportRequest) (*pb.FileResponse, error) {
-	log.Printf("Exporting STEP file")
+	ctxzap.Extract(ctx).Info("exporting step file", zap.String("asset_id", req.AssetId))
 
 	// Placeholder: STEPControl_Writer
 	return &pb.FileResponse{
@@ -86,16 +139,75 @@ func (s *server) ExportStep(ctx context.Context, req *pb.ExportRequest) (*pb.Fil
 }
 
 func main() {
-	lis, err := net.Listen("tcp", ":50051")
+	cfg := defaultConfig()
+
+	lis, err := net.Listen("tcp", cfg.GRPCAddr)
 	if err != nil {
 		log.Fatalf("failed to listen: %v", err)
 	}
 
-	s := grpc.NewServer()
-	pb.RegisterGeometryServiceServer(s, &server{})
+	logger, err := zap.NewProduction()
+	if err != nil {
+		log.Fatalf("failed to build logger: %v", err)
+	}
+	defer logger.Sync()
+
+	ht := newHealthTracker(cfg.OCCTFailureThreshold)
+	geometry := &server{health: ht, sessions: newSessionManager(sessionTTL)}
+
+	if len(cfg.JWTSecret) > 0 {
+		log.Fatal("QUTLAS_JWT_SECRET is set but verifyJWT has no real signature verification implemented; use mTLS (QUTLAS_TLS_CERT_FILE/KEY_FILE/CLIENT_CA_FILE) until it does")
+	}
+
+	serverOpts := buildInterceptors(cfg, logger, ht)
+	tlsOpt, err := serverTransportOption(cfg)
+	if err != nil {
+		log.Fatalf("invalid TLS config: %v", err)
+	}
+	if tlsOpt != nil {
+		serverOpts = append(serverOpts, tlsOpt)
+	} else if !cfg.DisableAuth && len(cfg.JWTSecret) == 0 {
+		log.Fatal("auth is enabled but mTLS (QUTLAS_TLS_CERT_FILE/KEY_FILE/CLIENT_CA_FILE) is not configured; set it or QUTLAS_DISABLE_AUTH=true for local/dev use")
+	}
+
+	s := grpc.NewServer(serverOpts...)
+	pb.RegisterGeometryServiceServer(s, geometry)
+	pb.RegisterSessionServiceServer(s, newSessionServer(geometry, geometry.sessions))
+	healthpb.RegisterHealthServer(s, ht.Server)
+	grpcprometheus.Register(s)
+
+	go func() {
+		log.Printf("gRPC OCCT server listening on %s", cfg.GRPCAddr)
+		if err := s.Serve(lis); err != nil {
+			log.Fatalf("failed to serve: %v", err)
+		}
+	}()
+
+	// Placeholder: initialize the OCCT backend (STEPControl_Reader, BRepMesh,
+	// ...) and confirm the first asset store is reachable before flipping the
+	// health status to SERVING.
+	ht.markOCCTReady()
+
+	ctx := context.Background()
+	go geometry.sessions.runReaper(ctx, sessionTTL/2)
+
+	gatewayCreds, err := gatewayDialOption(cfg)
+	if err != nil {
+		log.Fatalf("invalid TLS config for gateway dial: %v", err)
+	}
+	dialOpts := []grpc.DialOption{gatewayCreds}
+	gwMux, err := newGatewayMux(ctx, cfg.GRPCAddr, dialOpts)
+	if err != nil {
+		log.Fatalf("failed to build gateway: %v", err)
+	}
+
+	mux := newGatewayServeMux(gwMux)
+	mux.HandleFunc("/healthz", healthzHandler)
+	mux.HandleFunc("/readyz", readyzHandler(ht))
+	mux.Handle("/metrics", promhttp.Handler())
 
-	log.Println("gRPC OCCT server listening on :50051")
-	if err := s.Serve(lis); err != nil {
-		log.Fatalf("failed to serve: %v", err)
+	log.Printf("HTTP/JSON gateway listening on %s", cfg.GatewayAddr)
+	if err := http.ListenAndServe(cfg.GatewayAddr, mux); err != nil {
+		log.Fatalf("failed to serve gateway: %v", err)
 	}
 }
